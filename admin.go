@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+var (
+	adminUser     string
+	adminPassword string
+)
+
+// requireAdmin guards h with HTTP basic auth against ADMIN_USER and
+// ADMIN_PASSWORD. Admin routes are disabled entirely, and answer 404, when
+// no password is configured.
+func requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminPassword == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// Browsers resend basic-auth credentials on cross-site requests, so
+		// state-changing requests must come from this site.
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+				http.Error(w, "Cross-site request rejected", http.StatusForbidden)
+				return
+			}
+		}
+
+		h(w, r)
+	}
+}
+
+// isAdmin reports whether r carries valid admin credentials.
+func isAdmin(r *http.Request) bool {
+	if adminPassword == "" {
+		return false
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(adminUser)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(adminPassword)) == 1
+}
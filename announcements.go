@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// announcementCacheTTL is how long the active announcement is reused before
+// the database is asked again.
+const announcementCacheTTL = 30 * time.Second
+
+// Announcement is a site-wide banner shown between StartsAt and EndsAt.
+type Announcement struct {
+	ID       int       `json:"id"`
+	Message  string    `json:"message"`
+	Kind     string    `json:"kind"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Active   bool      `json:"active"`
+}
+
+const announcementColumns = "id, message, kind, starts_at, ends_at, active"
+
+func announcementFields(a *Announcement) []any {
+	return []any{&a.ID, &a.Message, &a.Kind, &a.StartsAt, &a.EndsAt, &a.Active}
+}
+
+// announcementCache holds the active announcement per site database.
+var announcementCache struct {
+	sync.Mutex
+	entries map[*sql.DB]*announcementCacheEntry
+}
+
+type announcementCacheEntry struct {
+	current   *Announcement
+	fetchedAt time.Time
+}
+
+// currentAnnouncement returns the announcement to display now, or nil. The
+// first request after the TTL claims the refresh and queries outside the
+// lock, so other requests keep getting the cached value meanwhile. A failed
+// query keeps the previous value until the next refresh.
+func currentAnnouncement(ctx context.Context) *Announcement {
+	conn := dbFrom(ctx)
+
+	announcementCache.Lock()
+	if announcementCache.entries == nil {
+		announcementCache.entries = map[*sql.DB]*announcementCacheEntry{}
+	}
+	entry, ok := announcementCache.entries[conn]
+	if !ok {
+		entry = &announcementCacheEntry{}
+		announcementCache.entries[conn] = entry
+	}
+	if time.Since(entry.fetchedAt) < announcementCacheTTL {
+		defer announcementCache.Unlock()
+		return entry.current
+	}
+	entry.fetchedAt = time.Now()
+	previous := entry.current
+	announcementCache.Unlock()
+
+	var a Announcement
+	err := conn.QueryRowContext(ctx, "SELECT "+announcementColumns+` FROM announcements
+		WHERE active AND NOW() BETWEEN starts_at AND ends_at
+		ORDER BY starts_at DESC LIMIT 1`).Scan(announcementFields(&a)...)
+	current := &a
+	switch {
+	case err == nil:
+	case errors.Is(err, sql.ErrNoRows):
+		current = nil
+	default:
+		log.Printf("Failed to fetch announcement: %v", err)
+		return previous
+	}
+
+	announcementCache.Lock()
+	entry.current = current
+	announcementCache.Unlock()
+	return current
+}
+
+// invalidateAnnouncementCache makes the next request for ctx's site fetch
+// the announcement again.
+func invalidateAnnouncementCache(ctx context.Context) {
+	announcementCache.Lock()
+	if entry, ok := announcementCache.entries[dbFrom(ctx)]; ok {
+		entry.fetchedAt = time.Time{}
+	}
+	announcementCache.Unlock()
+}
+
+func announcementCookie(id int) string {
+	return "dismissed_announcement_" + strconv.Itoa(id)
+}
+
+// announcementDismissed reports whether the reader has dismissed announcement id.
+func announcementDismissed(r *http.Request, id int) bool {
+	c, err := r.Cookie(announcementCookie(id))
+	return err == nil && c.Value == "1"
+}
+
+// visibleAnnouncement is the current announcement unless the reader has
+// dismissed it.
+func visibleAnnouncement(ctx context.Context, r *http.Request) *Announcement {
+	a := currentAnnouncement(ctx)
+	if a == nil || announcementDismissed(r, a.ID) {
+		return nil
+	}
+	return a
+}
+
+func dismissAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Announcement not found", http.StatusNotFound)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     announcementCookie(id),
+		Value:    "1",
+		Path:     "/",
+		MaxAge:   int((30 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// decodeAnnouncement reads and validates an announcement from a JSON body.
+// starts_at defaults to now and active to true.
+func decodeAnnouncement(r *http.Request) (Announcement, error) {
+	var req struct {
+		Message  string     `json:"message"`
+		Kind     string     `json:"kind"`
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   *time.Time `json:"ends_at"`
+		Active   *bool      `json:"active"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+		return Announcement{}, errors.New("request body must be JSON")
+	}
+
+	a := Announcement{
+		Message:  strings.TrimSpace(req.Message),
+		Kind:     req.Kind,
+		StartsAt: time.Now(),
+		Active:   true,
+	}
+	if a.Kind == "" {
+		a.Kind = "info"
+	}
+	if req.StartsAt != nil {
+		a.StartsAt = *req.StartsAt
+	}
+	if req.Active != nil {
+		a.Active = *req.Active
+	}
+
+	if a.Message == "" {
+		return a, errors.New("message is required")
+	}
+	if a.Kind != "info" && a.Kind != "warning" && a.Kind != "error" {
+		return a, errors.New("kind must be info, warning or error")
+	}
+	if req.EndsAt == nil {
+		return a, errors.New("ends_at is required")
+	}
+	a.EndsAt = *req.EndsAt
+	if !a.EndsAt.After(a.StartsAt) {
+		return a, errors.New("ends_at must be after starts_at")
+	}
+	return a, nil
+}
+
+func writeAnnouncement(w http.ResponseWriter, status int, a any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(a)
+}
+
+// announcementsHandler lists announcements on GET and creates one on POST.
+func announcementsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rows, err := dbFrom(r.Context()).QueryContext(r.Context(), "SELECT "+announcementColumns+" FROM announcements ORDER BY starts_at DESC")
+		if err != nil {
+			http.Error(w, "Failed to fetch announcements", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		announcements := []Announcement{}
+		for rows.Next() {
+			var a Announcement
+			if err := rows.Scan(announcementFields(&a)...); err != nil {
+				http.Error(w, "Error scanning announcements", http.StatusInternalServerError)
+				return
+			}
+			announcements = append(announcements, a)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Error scanning announcements", http.StatusInternalServerError)
+			return
+		}
+		writeAnnouncement(w, http.StatusOK, announcements)
+
+	case http.MethodPost:
+		a, err := decodeAnnouncement(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		err = dbFrom(r.Context()).QueryRowContext(r.Context(), `
+			INSERT INTO announcements (message, kind, starts_at, ends_at, active) VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+announcementColumns,
+			a.Message, a.Kind, a.StartsAt, a.EndsAt, a.Active).Scan(announcementFields(&a)...)
+		if err != nil {
+			http.Error(w, "Failed to create announcement", http.StatusInternalServerError)
+			return
+		}
+		invalidateAnnouncementCache(r.Context())
+		writeAnnouncement(w, http.StatusCreated, a)
+
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// announcementHandler reads, replaces or deletes a single announcement.
+func announcementHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Announcement not found", http.StatusNotFound)
+		return
+	}
+
+	var a Announcement
+	switch r.Method {
+	case http.MethodGet:
+		err = dbFrom(r.Context()).QueryRowContext(r.Context(), "SELECT "+announcementColumns+" FROM announcements WHERE id = $1", id).
+			Scan(announcementFields(&a)...)
+
+	case http.MethodPut:
+		if a, err = decodeAnnouncement(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		err = dbFrom(r.Context()).QueryRowContext(r.Context(), `
+			UPDATE announcements SET message = $2, kind = $3, starts_at = $4, ends_at = $5, active = $6
+			WHERE id = $1 RETURNING `+announcementColumns,
+			id, a.Message, a.Kind, a.StartsAt, a.EndsAt, a.Active).Scan(announcementFields(&a)...)
+
+	case http.MethodDelete:
+		err = dbFrom(r.Context()).QueryRowContext(r.Context(), "DELETE FROM announcements WHERE id = $1 RETURNING "+announcementColumns, id).
+			Scan(announcementFields(&a)...)
+
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Announcement not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to access announcement", http.StatusInternalServerError)
+		return
+	}
+	if r.Method != http.MethodGet {
+		invalidateAnnouncementCache(r.Context())
+	}
+	writeAnnouncement(w, http.StatusOK, a)
+}
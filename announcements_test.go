@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeAnnouncement(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+		check   func(t *testing.T, a Announcement)
+	}{
+		{
+			name: "defaults",
+			body: `{"message":" Hello ","ends_at":"2099-01-01T00:00:00Z"}`,
+			check: func(t *testing.T, a Announcement) {
+				if a.Message != "Hello" || a.Kind != "info" || !a.Active {
+					t.Errorf("got %+v, want trimmed message, kind info, active", a)
+				}
+				if time.Since(a.StartsAt) > time.Minute {
+					t.Errorf("StartsAt = %v, want now", a.StartsAt)
+				}
+			},
+		},
+		{
+			name: "explicit fields",
+			body: `{"message":"Down","kind":"error","starts_at":"2099-01-01T00:00:00Z","ends_at":"2099-01-02T00:00:00Z","active":false}`,
+			check: func(t *testing.T, a Announcement) {
+				if a.Kind != "error" || a.Active || a.StartsAt.Year() != 2099 {
+					t.Errorf("got %+v, want the supplied fields", a)
+				}
+			},
+		},
+		{name: "not JSON", body: `message=hi`, wantErr: "must be JSON"},
+		{name: "missing message", body: `{"message":"  ","ends_at":"2099-01-01T00:00:00Z"}`, wantErr: "message is required"},
+		{name: "unknown kind", body: `{"message":"Hi","kind":"urgent","ends_at":"2099-01-01T00:00:00Z"}`, wantErr: "kind must be"},
+		{name: "missing ends_at", body: `{"message":"Hi"}`, wantErr: "ends_at is required"},
+		{
+			name:    "ends before start",
+			body:    `{"message":"Hi","starts_at":"2099-01-02T00:00:00Z","ends_at":"2099-01-01T00:00:00Z"}`,
+			wantErr: "ends_at must be after starts_at",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/admin/announcements", strings.NewReader(tt.body))
+			a, err := decodeAnnouncement(r)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeAnnouncement: %v", err)
+			}
+			tt.check(t, a)
+		})
+	}
+}
+
+func TestAnnouncementDismissed(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		want   bool
+	}{
+		{"no cookie", nil, false},
+		{"matching announcement", &http.Cookie{Name: "dismissed_announcement_3", Value: "1"}, true},
+		{"other announcement", &http.Cookie{Name: "dismissed_announcement_4", Value: "1"}, false},
+		{"wrong value", &http.Cookie{Name: "dismissed_announcement_3", Value: "0"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			if got := announcementDismissed(r, 3); got != tt.want {
+				t.Errorf("announcementDismissed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDismissAnnouncementHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		id         string
+		wantStatus int
+		wantCookie bool
+	}{
+		{"dismiss", http.MethodPost, "3", http.StatusSeeOther, true},
+		{"wrong method", http.MethodGet, "3", http.StatusMethodNotAllowed, false},
+		{"bad id", http.MethodPost, "abc", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "/announcements/"+tt.id+"/dismiss", nil)
+			r.SetPathValue("id", tt.id)
+			dismissAnnouncementHandler(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			cookies := w.Result().Cookies()
+			if !tt.wantCookie {
+				if len(cookies) != 0 {
+					t.Errorf("got cookies %v, want none", cookies)
+				}
+				return
+			}
+			if len(cookies) != 1 {
+				t.Fatalf("got %d cookies, want 1", len(cookies))
+			}
+			c := cookies[0]
+			if c.Name != "dismissed_announcement_3" || c.Value != "1" {
+				t.Errorf("cookie = %s=%s, want dismissed_announcement_3=1", c.Name, c.Value)
+			}
+			if c.MaxAge <= 0 || !c.HttpOnly || c.Path != "/" {
+				t.Errorf("cookie = %+v, want a persistent HttpOnly cookie for /", c)
+			}
+
+			r = httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(c)
+			if !announcementDismissed(r, 3) {
+				t.Error("the set cookie does not dismiss the announcement")
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxContentWarningLen caps the author-supplied warning text, in runes.
+const maxContentWarningLen = 200
+
+// sanitizeContentWarning normalises author-supplied warning text: whitespace
+// is collapsed and the result truncated to maxContentWarningLen runes.
+// Escaping is left to html/template at render time.
+func sanitizeContentWarning(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) > maxContentWarningLen {
+		s = string([]rune(s)[:maxContentWarningLen])
+	}
+	return s
+}
+
+func contentWarningCookie(postID int) string {
+	return "cw_acked_" + strconv.Itoa(postID)
+}
+
+// contentWarningAcked reports whether the reader has already clicked through
+// the warning for postID in this browser session.
+func contentWarningAcked(r *http.Request, postID int) bool {
+	c, err := r.Cookie(contentWarningCookie(postID))
+	return err == nil && c.Value == "1"
+}
+
+// ackContentWarning records the acknowledgement in a session cookie so the
+// interstitial is only shown once.
+func ackContentWarning(w http.ResponseWriter, postID int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     contentWarningCookie(postID),
+		Value:    "1",
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// contentWarningGate decides whether post's content is shown to this reader.
+// A request carrying ack=1 records the acknowledgement and is redirected back
+// to the post; done reports that the response has been written.
+func contentWarningGate(w http.ResponseWriter, r *http.Request, post Post) (show, done bool) {
+	if post.ContentWarning == "" {
+		return true, false
+	}
+	if r.URL.Query().Get("ack") == "1" {
+		ackContentWarning(w, post.ID)
+		http.Redirect(w, r, "/post/view?id="+strconv.Itoa(post.ID), http.StatusSeeOther)
+		return false, true
+	}
+	return contentWarningAcked(r, post.ID), false
+}
+
+// contentWarningHandler sets or, given an empty value, clears a post's
+// content warning.
+func contentWarningHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		ContentWarning *string `json:"content_warning"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Request body must be JSON", http.StatusBadRequest)
+		return
+	}
+	var warning string
+	if req.ContentWarning != nil {
+		warning = sanitizeContentWarning(*req.ContentWarning)
+	}
+
+	var post Post
+	err = dbFrom(r.Context()).QueryRowContext(r.Context(),
+		"UPDATE posts SET content_warning = NULLIF($2, '') WHERE id = $1 RETURNING "+postColumns,
+		id, warning).Scan(postFields(&post)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update post", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentWarningAcked(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		want   bool
+	}{
+		{"no cookie", nil, false},
+		{"matching post", &http.Cookie{Name: "cw_acked_7", Value: "1"}, true},
+		{"other post", &http.Cookie{Name: "cw_acked_8", Value: "1"}, false},
+		{"wrong value", &http.Cookie{Name: "cw_acked_7", Value: "0"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/post/view?id=7", nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			if got := contentWarningAcked(r, 7); got != tt.want {
+				t.Errorf("contentWarningAcked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAckContentWarningSetsSessionCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	ackContentWarning(w, 7)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	c := cookies[0]
+	if c.Name != "cw_acked_7" || c.Value != "1" {
+		t.Errorf("cookie = %s=%s, want cw_acked_7=1", c.Name, c.Value)
+	}
+	if c.MaxAge != 0 || !c.Expires.IsZero() || c.RawExpires != "" {
+		t.Errorf("cookie is persistent (MaxAge=%d, Expires=%v), want a session cookie", c.MaxAge, c.Expires)
+	}
+	if !c.HttpOnly {
+		t.Error("cookie is not HttpOnly")
+	}
+}
+
+func TestContentWarningGate(t *testing.T) {
+	warned := Post{ID: 7, ContentWarning: "graphic descriptions"}
+
+	t.Run("no warning", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/post/view?id=7", nil)
+		show, done := contentWarningGate(w, r, Post{ID: 7})
+		if !show || done {
+			t.Errorf("show, done = %v, %v; want true, false", show, done)
+		}
+	})
+
+	t.Run("not acknowledged", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/post/view?id=7", nil)
+		show, done := contentWarningGate(w, r, warned)
+		if show || done {
+			t.Errorf("show, done = %v, %v; want false, false", show, done)
+		}
+	})
+
+	t.Run("ack sets cookie and redirects", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/post/view?id=7&ack=1", nil)
+		if _, done := contentWarningGate(w, r, warned); !done {
+			t.Fatal("done = false, want true")
+		}
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/post/view?id=7" {
+			t.Errorf("got %d to %q, want 303 to /post/view?id=7", w.Code, w.Header().Get("Location"))
+		}
+
+		// The cookie from the redirect bypasses the warning on the next view.
+		next := httptest.NewRequest(http.MethodGet, "/post/view?id=7", nil)
+		for _, c := range w.Result().Cookies() {
+			next.AddCookie(c)
+		}
+		show, done := contentWarningGate(httptest.NewRecorder(), next, warned)
+		if !show || done {
+			t.Errorf("after ack: show, done = %v, %v; want true, false", show, done)
+		}
+	})
+
+	t.Run("cookie for another post", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/post/view?id=7", nil)
+		r.AddCookie(&http.Cookie{Name: "cw_acked_8", Value: "1"})
+		if show, _ := contentWarningGate(httptest.NewRecorder(), r, warned); show {
+			t.Error("show = true for a cookie acknowledging a different post")
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Per-post custom HTML
+//
+// A post may carry raw HTML in its custom_head and custom_foot columns,
+// injected unescaped into the <head> and the end of the <body> of that
+// post's page. This is trusted content: it can run arbitrary script in the
+// site's origin. The security model is therefore:
+//
+//   - Only the admin API (behind requireAdmin) can write these columns. The
+//     public post form never reads them, and there is no contributor path
+//     to set them.
+//   - Nothing is rendered unless ALLOW_CUSTOM_POST_HTML is true. Turning the
+//     switch off disables every injection at once without touching data.
+//   - The values are size-limited but otherwise not sanitised; whoever holds
+//     the admin password is trusted with script access to the site.
+
+// maxCustomHTMLLen bounds each of custom_head and custom_foot, in bytes.
+const maxCustomHTMLLen = 64 << 10
+
+// allowCustomPostHTML is the global kill-switch for per-post custom HTML.
+var allowCustomPostHTML bool
+
+// loadCustomHTML returns the trusted head and foot HTML for a post.
+func loadCustomHTML(ctx context.Context, postID int) (head, foot template.HTML, err error) {
+	var h, f string
+	err = dbFrom(ctx).QueryRowContext(ctx,
+		"SELECT COALESCE(custom_head, ''), COALESCE(custom_foot, '') FROM posts WHERE id = $1",
+		postID).Scan(&h, &f)
+	return template.HTML(h), template.HTML(f), err
+}
+
+// customHTMLHandler sets a post's custom_head and custom_foot. Fields left
+// out of the request are unchanged; empty strings clear them.
+func customHTMLHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		CustomHead *string `json:"custom_head"`
+		CustomFoot *string `json:"custom_foot"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 3*maxCustomHTMLLen)).Decode(&req); err != nil {
+		http.Error(w, "Request body must be JSON", http.StatusBadRequest)
+		return
+	}
+	if (req.CustomHead != nil && len(*req.CustomHead) > maxCustomHTMLLen) ||
+		(req.CustomFoot != nil && len(*req.CustomFoot) > maxCustomHTMLLen) {
+		http.Error(w, "Custom HTML is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var resp struct {
+		ID         int    `json:"id"`
+		CustomHead string `json:"custom_head"`
+		CustomFoot string `json:"custom_foot"`
+	}
+	err = dbFrom(r.Context()).QueryRowContext(r.Context(), `
+		UPDATE posts SET
+			custom_head = CASE WHEN $2 THEN NULLIF($3, '') ELSE custom_head END,
+			custom_foot = CASE WHEN $4 THEN NULLIF($5, '') ELSE custom_foot END
+		WHERE id = $1
+		RETURNING id, COALESCE(custom_head, ''), COALESCE(custom_foot, '')`,
+		id,
+		req.CustomHead != nil, deref(req.CustomHead),
+		req.CustomFoot != nil, deref(req.CustomFoot),
+	).Scan(&resp.ID, &resp.CustomHead, &resp.CustomFoot)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update post", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxFeedImportEntries bounds how many entries one import call creates
+	// posts from.
+	maxFeedImportEntries = 50
+
+	// maxFeedSize bounds the feed document read from the remote server.
+	maxFeedSize = 5 << 20
+
+	// feedImportInterval is the minimum time between two feed imports.
+	feedImportInterval = time.Minute
+)
+
+var errUnsupportedFeed = errors.New("unsupported feed format")
+
+// safeHTTPClient is used for fetching user-supplied URLs. It refuses to
+// connect to loopback, private, link-local and other non-public addresses
+// so that it cannot be pointed at internal services.
+var safeHTTPClient = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: rejectNonPublicAddr,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		return checkFetchURL(req.URL)
+	},
+}
+
+func rejectNonPublicAddr(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+func checkFetchURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("URL has no host")
+	}
+	return nil
+}
+
+// FeedItem is a feed entry normalised from either RSS 2.0 or Atom 1.0.
+type FeedItem struct {
+	GUID    string
+	URL     string
+	Title   string
+	Content string
+}
+
+type rssFeed struct {
+	Items []struct {
+		GUID        string `xml:"guid"`
+		Link        string `xml:"link"`
+		Title       string `xml:"title"`
+		Description string `xml:"description"`
+		Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	} `xml:"channel>item"`
+}
+
+type atomFeed struct {
+	Entries []struct {
+		ID      string   `xml:"id"`
+		Title   atomText `xml:"title"`
+		Content atomText `xml:"content"`
+		Summary atomText `xml:"summary"`
+		Links   []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// atomText is an Atom text construct. Text and html content is character
+// data, but xhtml content is markup wrapped in a single div, which chardata
+// alone would read as empty.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// String returns the construct's content, with the xhtml wrapper div removed.
+func (t atomText) String() string {
+	if t.Type != "xhtml" {
+		return t.Text
+	}
+	inner := strings.TrimSpace(t.Inner)
+	start := strings.Index(inner, ">")
+	if !strings.HasPrefix(inner, "<") || start < 0 || inner[start-1] == '/' {
+		return inner
+	}
+	if end := strings.LastIndex(inner, "</"); end > start {
+		return strings.TrimSpace(inner[start+1 : end])
+	}
+	return inner
+}
+
+// parseFeed detects the feed format from its root element and returns its
+// entries in document order.
+func parseFeed(data []byte) ([]FeedItem, error) {
+	root, err := feedRoot(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []FeedItem
+	switch root {
+	case "rss":
+		var feed rssFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, err
+		}
+		for _, it := range feed.Items {
+			content := it.Encoded
+			if content == "" {
+				content = it.Description
+			}
+			entries = append(entries, FeedItem{
+				GUID:    firstNonEmpty(it.GUID, it.Link, it.Title),
+				URL:     strings.TrimSpace(it.Link),
+				Title:   it.Title,
+				Content: content,
+			})
+		}
+	case "feed":
+		var feed atomFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, err
+		}
+		for _, e := range feed.Entries {
+			var link string
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = l.Href
+					break
+				}
+			}
+			title := e.Title.String()
+			entries = append(entries, FeedItem{
+				GUID:    firstNonEmpty(e.ID, link, title),
+				URL:     strings.TrimSpace(link),
+				Title:   title,
+				Content: firstNonEmpty(e.Content.String(), e.Summary.String()),
+			})
+		}
+	default:
+		return nil, errUnsupportedFeed
+	}
+	return entries, nil
+}
+
+func feedRoot(data []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// HTTPClient is the subset of *http.Client used to fetch remote documents.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// FeedReader fetches and parses RSS 2.0 and Atom 1.0 feeds.
+type FeedReader struct {
+	HTTPClient HTTPClient
+}
+
+// feedReader fetches user-supplied feed URLs through safeHTTPClient.
+var feedReader = &FeedReader{HTTPClient: safeHTTPClient}
+
+// Fetch downloads feedURL and returns its items in document order.
+func (fr *FeedReader) Fetch(ctx context.Context, feedURL string) ([]FeedItem, error) {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFetchURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml, text/xml")
+
+	resp, err := fr.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseFeed(data)
+}
+
+// feedImportLimiter lets at most one feed import start per minute, since
+// each one makes the server fetch a remote document.
+var feedImportLimiter struct {
+	sync.Mutex
+	last time.Time
+}
+
+// reserveFeedImport claims the import slot, or returns how long to wait for
+// it to free up.
+func reserveFeedImport() time.Duration {
+	feedImportLimiter.Lock()
+	defer feedImportLimiter.Unlock()
+
+	if wait := feedImportInterval - time.Since(feedImportLimiter.last); wait > 0 {
+		return wait
+	}
+	feedImportLimiter.last = time.Now()
+	return 0
+}
+
+func importFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil || req.URL == "" {
+		http.Error(w, "Request body must be JSON with a url field", http.StatusBadRequest)
+		return
+	}
+	if u, err := url.Parse(req.URL); err != nil || checkFetchURL(u) != nil {
+		http.Error(w, "url must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+
+	// Only a request that is about to fetch uses up the slot.
+	if wait := reserveFeedImport(); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "Too many feed imports, please retry later", http.StatusTooManyRequests)
+		return
+	}
+
+	entries, err := feedReader.Fetch(r.Context(), req.URL)
+	if err != nil {
+		http.Error(w, "Failed to fetch feed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(entries) > maxFeedImportEntries {
+		entries = entries[:maxFeedImportEntries]
+	}
+
+	var result struct {
+		Imported int `json:"imported"`
+		Skipped  int `json:"skipped"`
+	}
+	for _, e := range entries {
+		if e.GUID == "" || e.Title == "" {
+			result.Skipped++
+			continue
+		}
+
+		// Imported posts start as drafts so third-party content is reviewed
+		// before it goes live. Conflicting on either source_guid or
+		// source_url means the item was imported before.
+		res, err := dbFrom(r.Context()).ExecContext(r.Context(),
+			"INSERT INTO posts (title, content, source_guid, source_url, draft) VALUES ($1, $2, $3, NULLIF($4, ''), TRUE) ON CONFLICT DO NOTHING",
+			e.Title, e.Content, e.GUID, e.URL)
+		if err != nil {
+			http.Error(w, "Failed to create post", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			result.Skipped++
+			continue
+		}
+		result.Imported++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// publishPostHandler takes a post, such as an imported draft, live.
+func publishPostHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	var post Post
+	err = dbFrom(r.Context()).QueryRowContext(r.Context(),
+		"UPDATE posts SET draft = FALSE WHERE id = $1 RETURNING "+postColumns, id).Scan(postFields(&post)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to publish post", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
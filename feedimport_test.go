@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const rssFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+  <channel>
+    <title>Fixture blog</title>
+    <item>
+      <title>Full content</title>
+      <link>https://example.com/full</link>
+      <guid>urn:post:1</guid>
+      <description>Short summary</description>
+      <content:encoded><![CDATA[<p>The whole post</p>]]></content:encoded>
+    </item>
+    <item>
+      <title>Description only</title>
+      <link> https://example.com/desc </link>
+      <description>Only a description</description>
+    </item>
+  </channel>
+</rss>`
+
+const atomFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Fixture blog</title>
+  <entry>
+    <id>urn:entry:1</id>
+    <title>With content</title>
+    <link rel="self" href="https://example.com/self"/>
+    <link rel="alternate" href="https://example.com/with-content"/>
+    <summary>Summary</summary>
+    <content>Body</content>
+  </entry>
+  <entry>
+    <id>urn:entry:2</id>
+    <title>Summary only</title>
+    <link href="https://example.com/summary"/>
+    <summary>Just a summary</summary>
+  </entry>
+</feed>`
+
+// serveFeed starts a server answering every request with status and body.
+func serveFeed(t *testing.T, status int, body string) (*httptest.Server, *FeedReader) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &FeedReader{HTTPClient: srv.Client()}
+}
+
+func TestFeedReaderFetchRSS(t *testing.T) {
+	srv, fr := serveFeed(t, http.StatusOK, rssFixture)
+
+	items, err := fr.Fetch(context.Background(), srv.URL+"/feed.xml")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := []FeedItem{
+		{GUID: "urn:post:1", URL: "https://example.com/full", Title: "Full content", Content: "<p>The whole post</p>"},
+		// Without a guid the link stands in; without content:encoded the
+		// description is used.
+		{GUID: "https://example.com/desc", URL: "https://example.com/desc", Title: "Description only", Content: "Only a description"},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items =\n%+v\nwant\n%+v", items, want)
+	}
+}
+
+func TestFeedReaderFetchAtom(t *testing.T) {
+	srv, fr := serveFeed(t, http.StatusOK, atomFixture)
+
+	items, err := fr.Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := []FeedItem{
+		{GUID: "urn:entry:1", URL: "https://example.com/with-content", Title: "With content", Content: "Body"},
+		{GUID: "urn:entry:2", URL: "https://example.com/summary", Title: "Summary only", Content: "Just a summary"},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items =\n%+v\nwant\n%+v", items, want)
+	}
+}
+
+func TestFeedReaderFetchAtomTextTypes(t *testing.T) {
+	srv, fr := serveFeed(t, http.StatusOK, `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <id>urn:entry:xhtml</id>
+    <title type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml">Marked <em>up</em></div></title>
+    <content type="xhtml">
+      <div xmlns="http://www.w3.org/1999/xhtml"><p>First</p><p>Second</p></div>
+    </content>
+  </entry>
+  <entry>
+    <id>urn:entry:html</id>
+    <title type="html">Escaped &amp;lt;b&amp;gt;</title>
+    <content type="html">&lt;p&gt;Escaped body&lt;/p&gt;</content>
+  </entry>
+</feed>`)
+
+	items, err := fr.Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := []FeedItem{
+		{GUID: "urn:entry:xhtml", Title: "Marked <em>up</em>", Content: "<p>First</p><p>Second</p>"},
+		{GUID: "urn:entry:html", Title: "Escaped &lt;b&gt;", Content: "<p>Escaped body</p>"},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items =\n%+v\nwant\n%+v", items, want)
+	}
+}
+
+func TestFeedReaderFetchNon200(t *testing.T) {
+	srv, fr := serveFeed(t, http.StatusNotFound, "missing")
+
+	_, err := fr.Fetch(context.Background(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Fetch error = %v, want a 404 status error", err)
+	}
+}
+
+func TestFeedReaderFetchUnsupportedFormat(t *testing.T) {
+	srv, fr := serveFeed(t, http.StatusOK, `<html><body>not a feed</body></html>`)
+
+	if _, err := fr.Fetch(context.Background(), srv.URL); err != errUnsupportedFeed {
+		t.Fatalf("Fetch error = %v, want %v", err, errUnsupportedFeed)
+	}
+}
+
+func TestFeedReaderFetchRejectsScheme(t *testing.T) {
+	fr := &FeedReader{HTTPClient: http.DefaultClient}
+
+	if _, err := fr.Fetch(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Fatal("Fetch accepted a file:// URL")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// ConcurrentFetcher runs a set of independent, named lookups in parallel
+// and collects their results.
+type ConcurrentFetcher[T any] struct {
+	names []string
+	fns   []func(ctx context.Context) (T, error)
+}
+
+// Add registers fn under name. The context passed to fn is cancelled as soon
+// as any other lookup fails or the context given to Execute is done.
+func (f *ConcurrentFetcher[T]) Add(name string, fn func(ctx context.Context) (T, error)) {
+	f.names = append(f.names, name)
+	f.fns = append(f.fns, fn)
+}
+
+// Execute runs every registered lookup in its own goroutine and waits for
+// all of them to return. The first error cancels the remaining lookups and
+// is returned alongside whatever results had been collected.
+func (f *ConcurrentFetcher[T]) Execute(ctx context.Context) (map[string]T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		results  = make(map[string]T, len(f.fns))
+	)
+
+	for i, fn := range f.fns {
+		wg.Add(1)
+		go func(name string, fn func(ctx context.Context) (T, error)) {
+			defer wg.Done()
+
+			v, err := fn(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			results[name] = v
+		}(f.names[i], fn)
+	}
+
+	wg.Wait()
+	return results, firstErr
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestConcurrentFetcherCollectsResults(t *testing.T) {
+	var f ConcurrentFetcher[int]
+	for i := 1; i <= 3; i++ {
+		f.Add(fmt.Sprintf("n%d", i), func(ctx context.Context) (int, error) {
+			return i * 10, nil
+		})
+	}
+
+	results, err := f.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	want := map[string]int{"n1": 10, "n2": 20, "n3": 30}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %v", len(results), len(want), results)
+	}
+	for name, v := range want {
+		if results[name] != v {
+			t.Errorf("results[%q] = %d, want %d", name, results[name], v)
+		}
+	}
+}
+
+func TestConcurrentFetcherFirstErrorCancelsOthers(t *testing.T) {
+	errBoom := errors.New("boom")
+	cancelled := make(chan error, 1)
+
+	var f ConcurrentFetcher[int]
+	f.Add("fails", func(ctx context.Context) (int, error) {
+		return 0, errBoom
+	})
+	f.Add("slow", func(ctx context.Context) (int, error) {
+		select {
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+			return 0, ctx.Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+			return 1, nil
+		}
+	})
+
+	start := time.Now()
+	results, err := f.Execute(context.Background())
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("Execute error = %v, want %v", err, errBoom)
+	}
+	if got := <-cancelled; !errors.Is(got, context.Canceled) {
+		t.Errorf("slow lookup saw %v, want context.Canceled", got)
+	}
+	if _, ok := results["slow"]; ok {
+		t.Errorf("cancelled lookup has a result: %v", results)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Execute took %v; cancellation did not stop the slow lookup", elapsed)
+	}
+}
+
+func TestConcurrentFetcherParentTimeout(t *testing.T) {
+	var f ConcurrentFetcher[int]
+	f.Add("slow", func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := f.Execute(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Execute error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+const (
+	benchLookups = 5
+	benchDelay   = 2 * time.Millisecond
+)
+
+func fakeLookup(ctx context.Context) (int, error) {
+	select {
+	case <-time.After(benchDelay):
+		return 1, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func BenchmarkLookupsSequential(b *testing.B) {
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		results := make(map[string]int, benchLookups)
+		for j := 0; j < benchLookups; j++ {
+			v, err := fakeLookup(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			results[fmt.Sprintf("q%d", j)] = v
+		}
+	}
+}
+
+func BenchmarkLookupsConcurrentFetcher(b *testing.B) {
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		var f ConcurrentFetcher[int]
+		for j := 0; j < benchLookups; j++ {
+			f.Add(fmt.Sprintf("q%d", j), fakeLookup)
+		}
+		if _, err := f.Execute(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,7 +3,8 @@ module blog-web
 go 1.23.5
 
 require (
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/tbxark/g4vercel v0.0.4 // indirect
+	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
 )
+
+require github.com/tbxark/g4vercel v0.0.4 // indirect
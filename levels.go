@@ -0,0 +1,14 @@
+package main
+
+// postLevels are the allowed difficulty levels, in increasing order. They
+// must match the post_level enum in the schema.
+var postLevels = []string{"beginner", "intermediate", "advanced"}
+
+func validLevel(level string) bool {
+	for _, l := range postLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
@@ -1,26 +1,52 @@
 package main
 
 import (
+    "context"
     "database/sql"
+    "errors"
     "html/template"
     "log"
+    "net"
     "net/http"
     "os"
+    "strconv"
+    "strings"
+    "time"
 
     _ "github.com/lib/pq" // PostgreSQL driver for NeonDB
     "github.com/joho/godotenv" // Load environment variables from .env file
 )
 
 type Post struct {
-    ID      int    `json:"id"`
-    Title   string `json:"title"`
-    Content string `json:"content"`
+    ID             int    `json:"id"`
+    Title          string `json:"title"`
+    Content        string `json:"content"`
+    ContentWarning string `json:"content_warning"`
+    Level          string `json:"level"`
+    SeriesID       int    `json:"series_id,omitempty"`
+    SeriesOrder    int    `json:"series_order,omitempty"`
+    Draft          bool   `json:"draft"`
 }
 
+// postColumns selects the fields of Post, in the order scanned by postFields.
+const postColumns = "id, title, content, COALESCE(content_warning, ''), COALESCE(level::text, ''), COALESCE(series_id, 0), COALESCE(series_order, 0), draft"
+
+func postFields(p *Post) []any {
+    return []any{&p.ID, &p.Title, &p.Content, &p.ContentWarning, &p.Level, &p.SeriesID, &p.SeriesOrder, &p.Draft}
+}
+
+// homeQueryTimeout bounds the queries behind the home page so a slow one
+// cannot stall the whole response.
+const homeQueryTimeout = 5 * time.Second
+
 var (
     db       *sql.DB
-    tmpl     = template.Must(template.ParseGlob("templates/*.html"))
+    tmpl     = template.Must(parseTemplates(""))
     dbConfig string
+
+    // feedIncludeWarned controls whether posts with a content warning are
+    // listed on the home page.
+    feedIncludeWarned bool
 )
 
 func main() {
@@ -37,6 +63,116 @@ func main() {
         log.Fatal("DB_URL is not set in the environment variables")
     }
 
+    // Populate the database instead of serving when asked to
+    if len(os.Args) > 1 && os.Args[1] == "seed" {
+        runSeed(os.Args[2:])
+        return
+    }
+
+    // Decide whether posts behind a content warning appear in the feed
+    if v := os.Getenv("FEED_INCLUDE_WARNED"); v != "" {
+        if feedIncludeWarned, err = strconv.ParseBool(v); err != nil {
+            log.Fatalf("Invalid FEED_INCLUDE_WARNED value %q: %v", v, err)
+        }
+    }
+
+    // Admin routes need a password; without one they stay disabled
+    adminUser = os.Getenv("ADMIN_USER")
+    if adminUser == "" {
+        adminUser = "admin"
+    }
+    adminPassword = os.Getenv("ADMIN_PASSWORD")
+
+    // Per-post custom HTML is trusted code and stays off unless enabled
+    if v := os.Getenv("ALLOW_CUSTOM_POST_HTML"); v != "" {
+        if allowCustomPostHTML, err = strconv.ParseBool(v); err != nil {
+            log.Fatalf("Invalid ALLOW_CUSTOM_POST_HTML value %q: %v", v, err)
+        }
+    }
+
+    // Summaries are generated through an OpenAI-compatible API, if configured
+    llmAPIURL = os.Getenv("LLM_API_URL")
+    llmAPIKey = os.Getenv("LLM_API_KEY")
+    llmModel = os.Getenv("LLM_MODEL")
+
+    // 404s are logged for the broken-link report unless turned off
+    if v := os.Getenv("TRACK_NOT_FOUND"); v != "" {
+        if trackNotFound, err = strconv.ParseBool(v); err != nil {
+            log.Fatalf("Invalid TRACK_NOT_FOUND value %q: %v", v, err)
+        }
+    }
+
+    // How long clients are told to wait while the server is starting
+    if v := os.Getenv("STARTUP_RETRY_AFTER"); v != "" {
+        if startupRetryAfter, err = strconv.Atoi(v); err != nil || startupRetryAfter < 0 {
+            log.Fatalf("Invalid STARTUP_RETRY_AFTER value %q", v)
+        }
+    }
+
+    // Set up routes
+    http.HandleFunc("/", homeHandler)
+    http.HandleFunc("/post/new", newPostHandler)
+    http.HandleFunc("/post/create", createPostHandler)
+    http.HandleFunc("/post/view", viewPostHandler)
+    http.HandleFunc("/series/{slug}", seriesHandler)
+    http.HandleFunc("/api/series/{slug}", apiSeriesHandler)
+    http.HandleFunc("/announcements/{id}/dismiss", dismissAnnouncementHandler)
+    http.HandleFunc("/healthz", healthzHandler)
+    http.HandleFunc("/readyz", readyzHandler)
+    http.HandleFunc("/api/import/feed", requireAdmin(importFeedHandler))
+    http.HandleFunc("/api/admin/feeds/import", requireAdmin(importFeedHandler))
+    http.HandleFunc("/api/admin/posts/{id}/publish", requireAdmin(publishPostHandler))
+    http.HandleFunc("/api/posts/{id}/content-warning", requireAdmin(contentWarningHandler))
+    http.HandleFunc("/api/admin/posts/{id}/custom-html", requireAdmin(customHTMLHandler))
+    http.HandleFunc("/api/posts/{id}/generate-summary", requireAdmin(generateSummaryHandler))
+    http.HandleFunc("/admin/404s", requireAdmin(notFoundReportHandler))
+    http.HandleFunc("/api/admin/announcements", requireAdmin(announcementsHandler))
+    http.HandleFunc("/api/admin/announcements/{id}", requireAdmin(announcementHandler))
+    http.HandleFunc("/api/admin/reports/content-quality", requireAdmin(contentQualityHandler))
+    http.HandleFunc("/admin/redirects", requireAdmin(redirectsHandler))
+    http.HandleFunc("/admin/redirects/delete", requireAdmin(deleteRedirectHandler))
+
+    // Configured redirects fire before routing
+    var handler http.Handler = redirectMiddleware(http.DefaultServeMux)
+
+    // Serve several blogs, chosen by request host, when tenants are configured
+    var resolver *EnvTenantResolver
+    if v := os.Getenv("TENANTS"); v != "" {
+        if resolver, err = NewEnvTenantResolver(v); err != nil {
+            log.Fatalf("Invalid TENANTS value: %v", err)
+        }
+        tenants = newTenantPool(migrate)
+        handler = tenantMiddleware(resolver, tenants)(handler)
+    }
+
+    // Profile slow requests when a profile directory is configured
+    if profileDir = os.Getenv("PROFILE_DIR"); profileDir != "" {
+        threshold := 2 * time.Second
+        if v := os.Getenv("SLOW_REQUEST_THRESHOLD"); v != "" {
+            if threshold, err = time.ParseDuration(v); err != nil {
+                log.Fatalf("Invalid SLOW_REQUEST_THRESHOLD value %q: %v", v, err)
+            }
+        }
+        if err = os.MkdirAll(profileDir, 0o755); err != nil {
+            log.Fatalf("Failed to create profile directory: %v", err)
+        }
+
+        http.HandleFunc("/admin/profiles", requireAdmin(listProfilesHandler))
+        http.HandleFunc("/admin/profiles/{file}", requireAdmin(downloadProfileHandler))
+        handler = slowRequestTracer(threshold)(handler)
+    }
+
+    // Start listening right away; requests get 503 until startup completes
+    ln, err := net.Listen("tcp", ":8080")
+    if err != nil {
+        log.Fatalf("Server failed to start: %v", err)
+    }
+    serverErr := make(chan error, 1)
+    go func() {
+        serverErr <- http.Serve(ln, readinessGate(handler))
+    }()
+    log.Println("Starting server on :8080...")
+
     // Initialize the database connection
     db, err = sql.Open("postgres", dbConfig)
     if err != nil {
@@ -49,42 +185,97 @@ func main() {
         log.Fatalf("Cannot ping the database: %v", err)
     }
 
-    // Set up routes
-    http.HandleFunc("/", homeHandler)
-    http.HandleFunc("/post/new", newPostHandler)
-    http.HandleFunc("/post/create", createPostHandler)
-    http.HandleFunc("/post/view", viewPostHandler)
+    // Apply schema changes
+    if err = migrate(db); err != nil {
+        log.Fatalf("Failed to migrate the database: %v", err)
+    }
 
-    // Start the server
-    log.Println("Starting server on :8080...")
-    if err := http.ListenAndServe(":8080", nil); err != nil {
-        log.Fatalf("Server failed to start: %v", err)
+    // Open and migrate every tenant's database before serving
+    if resolver != nil {
+        for _, t := range resolver.Tenants() {
+            if _, err = tenants.site(t); err != nil {
+                log.Fatalf("Failed to set up tenant %s: %v", t.Domain, err)
+            }
+        }
+    }
+
+    // Age out stale 404 entries
+    if trackNotFound {
+        go pruneNotFoundLog(time.Hour)
+    }
+
+    ready.Store(true)
+    log.Println("Startup complete, serving requests")
+
+    if err := <-serverErr; err != nil {
+        log.Fatalf("Server stopped: %v", err)
     }
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT id, title, content FROM posts")
+	if r.URL.Path != "/" {
+		notFound(w, r, "Page not found")
+		return
+	}
+
+	level := r.URL.Query().Get("level")
+	if level != "" && !validLevel(level) {
+		http.Error(w, "Invalid level", http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), homeQueryTimeout)
+	defer cancel()
+
+	var fetcher ConcurrentFetcher[any]
+	fetcher.Add("posts", func(ctx context.Context) (any, error) {
+		return fetchFeedPosts(ctx, level)
+	})
+	fetcher.Add("announcement", func(ctx context.Context) (any, error) {
+		return visibleAnnouncement(ctx, r), nil
+	})
+
+	results, err := fetcher.Execute(ctx)
 	if err != nil {
 		http.Error(w, "Failed to fetch posts", http.StatusInternalServerError)
 		return
 	}
+
+	templatesFrom(r.Context()).ExecuteTemplate(w, "home.html", struct {
+		Posts        []Post
+		Levels       []string
+		Level        string
+		Announcement *Announcement
+	}{results["posts"].([]Post), postLevels, level, results["announcement"].(*Announcement)})
+}
+
+// fetchFeedPosts lists the posts shown on the home page, optionally only
+// those at the given level.
+func fetchFeedPosts(ctx context.Context, level string) ([]Post, error) {
+	query := "SELECT " + postColumns + " FROM posts WHERE NOT draft AND ($1 = '' OR level::text = $1)"
+	if !feedIncludeWarned {
+		query += " AND content_warning IS NULL"
+	}
+
+	rows, err := dbFrom(ctx).QueryContext(ctx, query, level)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content); err != nil {
-			http.Error(w, "Error scanning posts", http.StatusInternalServerError)
-			return
+		if err := rows.Scan(postFields(&post)...); err != nil {
+			return nil, err
 		}
 		posts = append(posts, post)
 	}
-
-	tmpl.ExecuteTemplate(w, "home.html", posts)
+	return posts, rows.Err()
 }
 
 func newPostHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl.ExecuteTemplate(w, "new.html", nil)
+	templatesFrom(r.Context()).ExecuteTemplate(w, "new.html", postLevels)
 }
 
 func createPostHandler(w http.ResponseWriter, r *http.Request) {
@@ -95,8 +286,47 @@ func createPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	title := r.FormValue("title")
 	content := r.FormValue("content")
+	warning := sanitizeContentWarning(r.FormValue("content_warning"))
+	level := r.FormValue("level")
+	if level != "" && !validLevel(level) {
+		http.Error(w, "Invalid level", http.StatusUnprocessableEntity)
+		return
+	}
+
+	seriesTitle := strings.TrimSpace(r.FormValue("series"))
+	var seriesOrder int
+	if v := r.FormValue("series_order"); v != "" {
+		var err error
+		if seriesOrder, err = strconv.Atoi(v); err != nil || seriesOrder < 1 {
+			http.Error(w, "Part number must be a positive integer", http.StatusUnprocessableEntity)
+			return
+		}
+	}
 
-	_, err := db.Exec("INSERT INTO posts (title, content) VALUES ($1, $2)", title, content)
+	tx, err := dbFrom(r.Context()).BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Failed to create post", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var seriesID int
+	if seriesTitle != "" {
+		seriesID, seriesOrder, err = assignSeries(r.Context(), tx, seriesTitle, seriesOrder)
+		if errors.Is(err, errSeriesOrderTaken) || errors.Is(err, errSeriesTitle) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to create post", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	_, err = tx.Exec("INSERT INTO posts (title, content, content_warning, level, series_id, series_order) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, '')::post_level, NULLIF($5, 0), NULLIF($6, 0))", title, content, warning, level, seriesID, seriesOrder)
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		http.Error(w, "Failed to create post", http.StatusInternalServerError)
 		return
@@ -109,10 +339,48 @@ func viewPostHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 
 	var post Post
-	if err := db.QueryRow("SELECT id, title, content FROM posts WHERE id = $1", id).Scan(&post.ID, &post.Title, &post.Content); err != nil {
-		http.Error(w, "Post not found", http.StatusNotFound)
+	if err := dbFrom(r.Context()).QueryRowContext(r.Context(), "SELECT "+postColumns+" FROM posts WHERE id = $1", id).Scan(postFields(&post)...); err != nil {
+		notFound(w, r, "Post not found")
+		return
+	}
+
+	// Drafts, such as unreviewed feed imports, are only visible to admins.
+	if post.Draft && !isAdmin(r) {
+		notFound(w, r, "Post not found")
+		return
+	}
+
+	showContent, done := contentWarningGate(w, r, post)
+	if done {
 		return
 	}
 
-	tmpl.ExecuteTemplate(w, "view.html", post)
+	data := struct {
+		Post
+		ShowContent bool
+		CustomHead  template.HTML
+		CustomFoot  template.HTML
+		Series      *Series
+	}{
+		Post:        post,
+		ShowContent: showContent,
+	}
+
+	if post.SeriesID != 0 {
+		var err error
+		if data.Series, err = loadSeries(r.Context(), "id", post.SeriesID); err != nil {
+			http.Error(w, "Failed to load post", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if allowCustomPostHTML {
+		var err error
+		if data.CustomHead, data.CustomFoot, err = loadCustomHTML(r.Context(), post.ID); err != nil {
+			http.Error(w, "Failed to load post", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	templatesFrom(r.Context()).ExecuteTemplate(w, "view.html", data)
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// maxTrackedPathLen and maxTrackedReferrerLen truncate what is stored for
+	// a 404 so that junk requests cannot bloat the log.
+	maxTrackedPathLen     = 2048
+	maxTrackedReferrerLen = 1024
+
+	// notFoundRetention is how long a path stays in not_found_log after its
+	// last hit, so paths made up by scanners age out.
+	notFoundRetention = 30 * 24 * time.Hour
+)
+
+// trackNotFound controls whether 404s are recorded in not_found_log.
+var trackNotFound = true
+
+// notFound records the request in not_found_log and answers 404 with msg.
+func notFound(w http.ResponseWriter, r *http.Request, msg string) {
+	if trackNotFound {
+		recordNotFound(r)
+	}
+	http.Error(w, msg, http.StatusNotFound)
+}
+
+func recordNotFound(r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+	defer cancel()
+
+	// One row per path; only the latest referrer is kept as a sample.
+	_, err := dbFrom(ctx).ExecContext(ctx, `
+		INSERT INTO not_found_log (path, last_referrer) VALUES ($1, $2)
+		ON CONFLICT (path) DO UPDATE
+		SET hits = not_found_log.hits + 1, last_seen = NOW(),
+			last_referrer = COALESCE(NULLIF(EXCLUDED.last_referrer, ''), not_found_log.last_referrer)`,
+		truncate(trackedPath(r), maxTrackedPathLen), truncate(r.Referer(), maxTrackedReferrerLen))
+	if err != nil {
+		log.Printf("Failed to record 404 for %s: %v", r.URL.Path, err)
+	}
+}
+
+// trackedPath is the key a 404 is counted under: the request path, except
+// that a missing post keeps its ID since every post shares /post/view.
+// Other query parameters are dropped so tracking junk does not split counts.
+func trackedPath(r *http.Request) string {
+	if r.URL.Path == "/post/view" {
+		if id := r.URL.Query().Get("id"); id != "" {
+			return "/post/view?id=" + url.QueryEscape(id)
+		}
+	}
+	return r.URL.Path
+}
+
+// pruneNotFoundLog deletes paths not requested within notFoundRetention from
+// every site's log, once at startup and then every interval.
+func pruneNotFoundLog(interval time.Duration) {
+	for {
+		for _, conn := range siteDBs() {
+			res, err := conn.Exec("DELETE FROM not_found_log WHERE last_seen < $1", time.Now().Add(-notFoundRetention))
+			if err != nil {
+				log.Printf("Failed to prune 404 log: %v", err)
+			} else if n, _ := res.RowsAffected(); n > 0 {
+				log.Printf("Pruned %d stale paths from the 404 log", n)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+type notFoundReport struct {
+	Path         string
+	Hits         int
+	LastReferrer string
+	LastSeen     time.Time
+	Redirected   bool
+}
+
+// Redirectable reports whether a redirect rule can match the path. Rules
+// match on the path alone, so entries such as a missing post ID cannot.
+func (e notFoundReport) Redirectable() bool {
+	return !strings.ContainsAny(e.Path, "?#")
+}
+
+// notFoundReportHandler lists the most requested missing paths.
+func notFoundReportHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := dbFrom(r.Context()).QueryContext(r.Context(), `
+		SELECT l.path, l.hits, l.last_referrer, l.last_seen, EXISTS (
+			SELECT 1 FROM redirects rd
+			WHERE rd.from_path = l.path
+			   OR (rd.from_path LIKE '%/*' AND starts_with(l.path, left(rd.from_path, -1))))
+		FROM not_found_log l
+		ORDER BY l.hits DESC
+		LIMIT 100`)
+	if err != nil {
+		http.Error(w, "Failed to fetch 404 report", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var report []notFoundReport
+	for rows.Next() {
+		var e notFoundReport
+		if err := rows.Scan(&e.Path, &e.Hits, &e.LastReferrer, &e.LastSeen, &e.Redirected); err != nil {
+			http.Error(w, "Error scanning 404 report", http.StatusInternalServerError)
+			return
+		}
+		report = append(report, e)
+	}
+
+	templatesFrom(r.Context()).ExecuteTemplate(w, "admin_404s.html", report)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrackedPath(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/missing", "/missing"},
+		{"/missing?utm_source=a", "/missing"},
+		{"/post/view?id=42", "/post/view?id=42"},
+		{"/post/view?id=42&utm_source=a", "/post/view?id=42"},
+		{"/post/view", "/post/view"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if got := trackedPath(r); got != tt.want {
+			t.Errorf("trackedPath(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestNotFoundReportRedirectable(t *testing.T) {
+	if !(notFoundReport{Path: "/old-page"}).Redirectable() {
+		t.Error("plain path is not redirectable")
+	}
+	if (notFoundReport{Path: "/post/view?id=42"}).Redirectable() {
+		t.Error("missing post entry is offered as a redirect source")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// contentQualityCacheTTL is how long a computed report is served before the
+// posts are scored again.
+const contentQualityCacheTTL = time.Hour
+
+// qualitySignal is one check a post can pass to earn points.
+type qualitySignal struct {
+	Name   string
+	Points int
+}
+
+// qualitySignals are the checks scored today, in report order. Cover images,
+// tags, categories and edit times are not stored for posts, so those checks
+// are left out and the best possible score is maxQualityScore, not 100.
+var qualitySignals = []qualitySignal{
+	{"excerpt", 10},
+	{"word_count", 20},
+	{"reading_time", 10},
+	{"title_length", 10},
+	{"internal_link", 10},
+}
+
+var maxQualityScore = func() int {
+	total := 0
+	for _, s := range qualitySignals {
+		total += s.Points
+	}
+	return total
+}()
+
+// ContentQuality is one row of the content-quality report.
+type ContentQuality struct {
+	PostID   int      `json:"post_id"`
+	Title    string   `json:"title"`
+	Score    int      `json:"score"`
+	MaxScore int      `json:"max_score"`
+	Missing  []string `json:"missing"`
+}
+
+// scoreContent totals the points for the signals a post passed, keyed by
+// signal name, and lists the ones it missed.
+func scoreContent(passed map[string]bool) (score int, missing []string) {
+	missing = []string{}
+	for _, s := range qualitySignals {
+		if passed[s.Name] {
+			score += s.Points
+		} else {
+			missing = append(missing, s.Name)
+		}
+	}
+	return score, missing
+}
+
+// contentQualityQuery evaluates every signal for every post. Reading time
+// assumes 200 words a minute, so under 15 minutes is under 3000 words. An
+// internal link is an href or Markdown link to a path on this site.
+const contentQualityQuery = `
+	SELECT id, title,
+		COALESCE(btrim(excerpt), '') <> '',
+		words >= 300,
+		words < 3000,
+		char_length(title) BETWEEN 20 AND 80,
+		content ~ '(href=["'']|\]\()/([^/]|$)'
+	FROM (
+		SELECT id, title, content, excerpt,
+			CASE WHEN btrim(content) = '' THEN 0
+				ELSE array_length(regexp_split_to_array(btrim(content), '\s+'), 1)
+			END AS words
+		FROM posts
+	) p`
+
+func fetchContentQuality(ctx context.Context, conn *sql.DB) ([]ContentQuality, error) {
+	rows, err := conn.QueryContext(ctx, contentQualityQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []ContentQuality
+	for rows.Next() {
+		var (
+			q      = ContentQuality{MaxScore: maxQualityScore}
+			passed = make([]bool, len(qualitySignals))
+			dest   = []any{&q.PostID, &q.Title}
+		)
+		for i := range passed {
+			dest = append(dest, &passed[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		byName := make(map[string]bool, len(passed))
+		for i, s := range qualitySignals {
+			byName[s.Name] = passed[i]
+		}
+		q.Score, q.Missing = scoreContent(byName)
+		report = append(report, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(report, func(i, j int) bool {
+		if report[i].Score != report[j].Score {
+			return report[i].Score < report[j].Score
+		}
+		return report[i].PostID < report[j].PostID
+	})
+	return report, nil
+}
+
+// contentQualityCache holds the latest report per site database.
+var contentQualityCache struct {
+	sync.Mutex
+	entries map[*sql.DB]contentQualityEntry
+}
+
+type contentQualityEntry struct {
+	report     []ContentQuality
+	computedAt time.Time
+}
+
+// cachedContentQuality returns the report for ctx's site, scoring the posts
+// again once the cached one is older than contentQualityCacheTTL.
+func cachedContentQuality(ctx context.Context) ([]ContentQuality, error) {
+	conn := dbFrom(ctx)
+
+	contentQualityCache.Lock()
+	entry, ok := contentQualityCache.entries[conn]
+	contentQualityCache.Unlock()
+	if ok && time.Since(entry.computedAt) < contentQualityCacheTTL {
+		return entry.report, nil
+	}
+
+	report, err := fetchContentQuality(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	contentQualityCache.Lock()
+	if contentQualityCache.entries == nil {
+		contentQualityCache.entries = map[*sql.DB]contentQualityEntry{}
+	}
+	contentQualityCache.entries[conn] = contentQualityEntry{report, time.Now()}
+	contentQualityCache.Unlock()
+	return report, nil
+}
+
+// filterMinScore returns the rows scoring at least min, keeping their order.
+func filterMinScore(report []ContentQuality, min int) []ContentQuality {
+	filtered := []ContentQuality{}
+	for _, q := range report {
+		if q.Score >= min {
+			filtered = append(filtered, q)
+		}
+	}
+	return filtered
+}
+
+// contentQualityHandler lists every post with its quality score, lowest
+// first, optionally only those scoring at least ?min_score.
+func contentQualityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	minScore := 0
+	if v := r.URL.Query().Get("min_score"); v != "" {
+		var err error
+		if minScore, err = strconv.Atoi(v); err != nil || minScore < 0 {
+			http.Error(w, "Invalid min_score", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	report, err := cachedContentQuality(r.Context())
+	if err != nil {
+		log.Printf("Failed to score posts: %v", err)
+		http.Error(w, "Failed to build content-quality report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filterMinScore(report, minScore))
+}
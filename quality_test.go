@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestScoreContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		passed      map[string]bool
+		wantScore   int
+		wantMissing []string
+	}{
+		{
+			name:        "nothing passed",
+			passed:      nil,
+			wantScore:   0,
+			wantMissing: []string{"excerpt", "word_count", "reading_time", "title_length", "internal_link"},
+		},
+		{
+			name: "everything passed",
+			passed: map[string]bool{
+				"excerpt": true, "word_count": true, "reading_time": true, "title_length": true, "internal_link": true,
+			},
+			wantScore:   60,
+			wantMissing: []string{},
+		},
+		{
+			name:        "short post with a good title",
+			passed:      map[string]bool{"reading_time": true, "title_length": true},
+			wantScore:   20,
+			wantMissing: []string{"excerpt", "word_count", "internal_link"},
+		},
+		{
+			name:        "word count is worth double",
+			passed:      map[string]bool{"excerpt": true, "word_count": true},
+			wantScore:   30,
+			wantMissing: []string{"reading_time", "title_length", "internal_link"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, missing := scoreContent(tt.passed)
+			if score != tt.wantScore {
+				t.Errorf("score = %d, want %d", score, tt.wantScore)
+			}
+			if !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestMaxQualityScore(t *testing.T) {
+	if maxQualityScore != 60 {
+		t.Errorf("maxQualityScore = %d, want 60", maxQualityScore)
+	}
+}
+
+func TestFilterMinScore(t *testing.T) {
+	report := []ContentQuality{{PostID: 1, Score: 10}, {PostID: 2, Score: 50}, {PostID: 3, Score: 60}}
+	tests := []struct {
+		min  int
+		want []int
+	}{
+		{0, []int{1, 2, 3}},
+		{50, []int{2, 3}},
+		{61, []int{}},
+	}
+	for _, tt := range tests {
+		ids := []int{}
+		for _, q := range filterMinScore(report, tt.min) {
+			ids = append(ids, q.PostID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("filterMinScore(%d) = %v, want %v", tt.min, ids, tt.want)
+		}
+	}
+}
+
+func TestContentQualityHandlerRejectsBadMinScore(t *testing.T) {
+	for _, v := range []string{"abc", "-1"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/admin/reports/content-quality?min_score="+v, nil)
+		contentQualityHandler(w, r)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("min_score=%s: status = %d, want 422", v, w.Code)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ready flips to true once the database is connected and migrated.
+	ready atomic.Bool
+
+	// startupRetryAfter is sent as Retry-After, in seconds, while the
+	// server is still starting.
+	startupRetryAfter = 5
+)
+
+// readinessGate answers every route except the health checks with 503
+// until startup has completed.
+func readinessGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() && r.URL.Path != "/healthz" && r.URL.Path != "/readyz" {
+			w.Header().Set("Retry-After", strconv.Itoa(startupRetryAfter))
+			http.Error(w, "Service is starting, please retry shortly", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// healthzHandler reports that the process is up and serving.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// readyzHandler reports whether the server can handle traffic: startup has
+// completed and every site's database still answers.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		w.Header().Set("Retry-After", strconv.Itoa(startupRetryAfter))
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	for _, conn := range siteDBs() {
+		if err := conn.PingContext(ctx); err != nil {
+			http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.Write([]byte("ready\n"))
+}
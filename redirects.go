@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxRedirectHops is how far a redirect chain is followed when checking a
+// new rule for loops.
+const maxRedirectHops = 10
+
+var errRedirectLoop = errors.New("redirect would create a loop")
+
+// Redirect maps a request path to another local path or a post. A FromPath
+// ending in "/*" matches every path under that prefix; if ToPath also ends
+// in "/*" the matched remainder is carried over.
+type Redirect struct {
+	FromPath string
+	ToPath   string
+	ToPostID sql.NullInt64
+	Status   int
+}
+
+// target returns where r sends path, which must be matched by r. ok is
+// false when the path carried over by a wildcard would turn the target into
+// something other than a local path, such as "//evil.example/x"; such a
+// request is not redirected.
+func (r Redirect) target(path string) (to string, ok bool) {
+	if r.ToPostID.Valid {
+		return "/post/view?id=" + strconv.FormatInt(r.ToPostID.Int64, 10), true
+	}
+	if strings.HasSuffix(r.FromPath, "/*") && strings.HasSuffix(r.ToPath, "/*") {
+		rest := strings.TrimPrefix(path, strings.TrimSuffix(r.FromPath, "*"))
+		if strings.HasPrefix(rest, "/") || strings.Contains(rest, "\\") {
+			return "", false
+		}
+		to = strings.TrimSuffix(r.ToPath, "*") + rest
+	} else {
+		to = r.ToPath
+	}
+	return to, isLocalPath(to)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// findRedirect returns the rule matching path, preferring an exact match
+// over the longest matching prefix.
+func findRedirect(ctx context.Context, q querier, path string) (Redirect, error) {
+	var rd Redirect
+	err := q.QueryRowContext(ctx, `
+		SELECT from_path, COALESCE(to_path, ''), to_post_id, status
+		FROM redirects
+		WHERE from_path = $1
+		   OR (from_path LIKE '%/*' AND starts_with($1, left(from_path, -1)))
+		ORDER BY from_path = $1 DESC, length(from_path) DESC
+		LIMIT 1`, path).Scan(&rd.FromPath, &rd.ToPath, &rd.ToPostID, &rd.Status)
+	return rd, err
+}
+
+// redirectExempt reports whether path is never redirected, so that a bad
+// rule cannot lock operators out of the admin or health endpoints.
+func redirectExempt(path string) bool {
+	return path == "/healthz" || path == "/readyz" ||
+		strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/api/")
+}
+
+// redirectMiddleware applies configured redirects before routing.
+func redirectMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if redirectExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rd, err := findRedirect(r.Context(), dbFrom(r.Context()), r.URL.Path)
+		if err == nil {
+			if to, ok := rd.target(r.URL.Path); ok {
+				http.Redirect(w, r, to, rd.Status)
+				return
+			}
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to resolve redirect for %s: %v", r.URL.Path, err)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validateRedirect checks a rule's shape. Targets must be local paths so the
+// table cannot be used as an open redirect.
+func validateRedirect(rd Redirect) error {
+	if !isLocalPath(rd.FromPath) {
+		return errors.New("from path must start with a single /")
+	}
+	if redirectExempt(rd.FromPath) || rd.FromPath == "/" {
+		return fmt.Errorf("%s cannot be redirected", rd.FromPath)
+	}
+	if strings.Contains(strings.TrimSuffix(rd.FromPath, "/*"), "*") {
+		return errors.New("a wildcard is only allowed as a trailing /*")
+	}
+	// Rules match on the request path only, so these could never fire.
+	if strings.ContainsAny(rd.FromPath, "?#") {
+		return errors.New("from path cannot contain a query string or fragment")
+	}
+
+	if rd.ToPostID.Valid == (rd.ToPath != "") {
+		return errors.New("set exactly one of to path and to post")
+	}
+	if rd.ToPath != "" {
+		if !isLocalPath(rd.ToPath) {
+			return errors.New("to path must start with a single /")
+		}
+		if strings.Contains(strings.TrimSuffix(rd.ToPath, "/*"), "*") {
+			return errors.New("a wildcard is only allowed as a trailing /*")
+		}
+		if strings.HasSuffix(rd.ToPath, "/*") && !strings.HasSuffix(rd.FromPath, "/*") {
+			return errors.New("to path can only use a wildcard when from path does")
+		}
+	}
+
+	if rd.Status != http.StatusMovedPermanently && rd.Status != http.StatusFound {
+		return errors.New("status must be 301 or 302")
+	}
+	return nil
+}
+
+func isLocalPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") && !strings.Contains(p, "\\")
+}
+
+// samplePath returns a concrete path matched by p, standing in for every
+// path under a wildcard.
+func samplePath(p string) string {
+	if strings.HasSuffix(p, "/*") {
+		return strings.TrimSuffix(p, "*") + "loop-check"
+	}
+	return p
+}
+
+// checkRedirectLoop fails if saving rd, whose rule must already be visible
+// through q, lets some chain revisit a path or not settle within
+// maxRedirectHops. The chain from rd itself is followed and, for a wildcard
+// rule, so is the chain from every rule that starts or ends under its
+// prefix, since the wildcard can close a loop through those concrete paths.
+func checkRedirectLoop(ctx context.Context, q querier, rd Redirect) error {
+	starts := []string{samplePath(rd.FromPath)}
+	if strings.HasSuffix(rd.FromPath, "/*") {
+		related, err := rulePathsUnder(ctx, q, rd.FromPath)
+		if err != nil {
+			return err
+		}
+		starts = append(starts, related...)
+	}
+
+	for _, start := range starts {
+		if err := walkRedirects(ctx, q, start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rulePathsUnder returns sample paths for the from and to paths of every
+// other rule that fall under the wildcard rule fromPath.
+func rulePathsUnder(ctx context.Context, q querier, fromPath string) ([]string, error) {
+	prefix := strings.TrimSuffix(fromPath, "*")
+	rows, err := q.QueryContext(ctx, `
+		SELECT from_path, COALESCE(to_path, '') FROM redirects
+		WHERE from_path <> $1 AND (starts_with(from_path, $2) OR starts_with(to_path, $2))`,
+		fromPath, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var from, to string
+		if err := rows.Scan(&from, &to); err != nil {
+			return nil, err
+		}
+		paths = append(paths, samplePath(from))
+		if strings.HasPrefix(to, prefix) {
+			paths = append(paths, samplePath(to))
+		}
+	}
+	return paths, rows.Err()
+}
+
+// walkRedirects follows the chain from path and fails if it revisits a path
+// or does not settle within maxRedirectHops.
+func walkRedirects(ctx context.Context, q querier, path string) error {
+	seen := map[string]bool{}
+	for hop := 0; hop < maxRedirectHops; hop++ {
+		if seen[path] {
+			return errRedirectLoop
+		}
+		seen[path] = true
+
+		if redirectExempt(path) {
+			return nil
+		}
+		next, err := findRedirect(ctx, q, path)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		to, ok := next.target(path)
+		if !ok {
+			return nil
+		}
+		path, _, _ = strings.Cut(to, "?")
+	}
+	return errRedirectLoop
+}
+
+func listRedirects(ctx context.Context) ([]Redirect, error) {
+	rows, err := dbFrom(ctx).QueryContext(ctx,
+		"SELECT from_path, COALESCE(to_path, ''), to_post_id, status FROM redirects ORDER BY from_path")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var redirects []Redirect
+	for rows.Next() {
+		var rd Redirect
+		if err := rows.Scan(&rd.FromPath, &rd.ToPath, &rd.ToPostID, &rd.Status); err != nil {
+			return nil, err
+		}
+		redirects = append(redirects, rd)
+	}
+	return redirects, rows.Err()
+}
+
+// redirectsHandler lists redirects on GET and saves one on POST.
+func redirectsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		redirects, err := listRedirects(r.Context())
+		if err != nil {
+			http.Error(w, "Failed to fetch redirects", http.StatusInternalServerError)
+			return
+		}
+		templatesFrom(r.Context()).ExecuteTemplate(w, "admin_redirects.html", struct {
+			Redirects []Redirect
+			From      string
+		}{redirects, r.URL.Query().Get("from")})
+
+	case http.MethodPost:
+		saveRedirectHandler(w, r)
+
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+func saveRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	rd := Redirect{
+		FromPath: strings.TrimSpace(r.FormValue("from_path")),
+		ToPath:   strings.TrimSpace(r.FormValue("to_path")),
+		Status:   http.StatusMovedPermanently,
+	}
+	if v := r.FormValue("to_post_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusUnprocessableEntity)
+			return
+		}
+		rd.ToPostID = sql.NullInt64{Int64: id, Valid: true}
+	}
+	if v := r.FormValue("status"); v != "" {
+		rd.Status, _ = strconv.Atoi(v)
+	}
+	if err := validateRedirect(rd); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	tx, err := dbFrom(r.Context()).BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Failed to save redirect", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(r.Context(), `
+		INSERT INTO redirects (from_path, to_path, to_post_id, status) VALUES ($1, NULLIF($2, ''), $3, $4)
+		ON CONFLICT (from_path) DO UPDATE
+		SET to_path = EXCLUDED.to_path, to_post_id = EXCLUDED.to_post_id, status = EXCLUDED.status`,
+		rd.FromPath, rd.ToPath, rd.ToPostID, rd.Status)
+	if err != nil {
+		http.Error(w, "Failed to save redirect", http.StatusUnprocessableEntity)
+		return
+	}
+
+	// The new rule is visible inside the transaction, so the chain is
+	// checked exactly as it would be served.
+	if err := checkRedirectLoop(r.Context(), tx, rd); err != nil {
+		if errors.Is(err, errRedirectLoop) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, "Failed to save redirect", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to save redirect", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/redirects", http.StatusSeeOther)
+}
+
+func deleteRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := dbFrom(r.Context()).ExecContext(r.Context(), "DELETE FROM redirects WHERE from_path = $1", r.FormValue("from_path")); err != nil {
+		http.Error(w, "Failed to delete redirect", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/redirects", http.StatusSeeOther)
+}
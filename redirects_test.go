@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestValidateRedirect(t *testing.T) {
+	tests := []struct {
+		name    string
+		rd      Redirect
+		wantErr bool
+	}{
+		{"exact path", Redirect{FromPath: "/old", ToPath: "/new", Status: 301}, false},
+		{"wildcard", Redirect{FromPath: "/old/*", ToPath: "/new/*", Status: 302}, false},
+		{"to post", Redirect{FromPath: "/old", ToPostID: sql.NullInt64{Int64: 3, Valid: true}, Status: 301}, false},
+		{"query in from path", Redirect{FromPath: "/old?page=2", ToPath: "/new", Status: 301}, true},
+		{"fragment in from path", Redirect{FromPath: "/old#top", ToPath: "/new", Status: 301}, true},
+		{"external target", Redirect{FromPath: "/old", ToPath: "//evil.example", Status: 301}, true},
+		{"admin path", Redirect{FromPath: "/admin/redirects", ToPath: "/", Status: 301}, true},
+		{"inner wildcard", Redirect{FromPath: "/a/*/b", ToPath: "/c", Status: 301}, true},
+		{"wildcard target only", Redirect{FromPath: "/a", ToPath: "/b/*", Status: 301}, true},
+		{"both targets", Redirect{FromPath: "/a", ToPath: "/b", ToPostID: sql.NullInt64{Int64: 1, Valid: true}, Status: 301}, true},
+		{"bad status", Redirect{FromPath: "/a", ToPath: "/b", Status: 307}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRedirect(tt.rd); (err != nil) != tt.wantErr {
+				t.Errorf("validateRedirect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedirectTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		rd     Redirect
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"exact", Redirect{FromPath: "/old", ToPath: "/new"}, "/old", "/new", true},
+		{"to post", Redirect{FromPath: "/old", ToPostID: sql.NullInt64{Int64: 4, Valid: true}}, "/old", "/post/view?id=4", true},
+		{"wildcard carries rest", Redirect{FromPath: "/old/*", ToPath: "/new/*"}, "/old/a/b", "/new/a/b", true},
+		{"wildcard to fixed target", Redirect{FromPath: "/old/*", ToPath: "/new"}, "/old/a", "/new", true},
+		{"root wildcard", Redirect{FromPath: "/old/*", ToPath: "/*"}, "/old/a", "/a", true},
+		{"protocol-relative via double slash", Redirect{FromPath: "/old/*", ToPath: "/*"}, "/old//evil.example/x", "", false},
+		{"protocol-relative via decoded %2F", Redirect{FromPath: "/old/*", ToPath: "/*"}, "/old/" + "/evil.example", "", false},
+		{"backslash at start", Redirect{FromPath: "/old/*", ToPath: "/*"}, `/old/\evil.example`, "", false},
+		{"backslash inside rest", Redirect{FromPath: "/old/*", ToPath: "/new/*"}, `/old/a\b`, "", false},
+		{"double slash under prefix", Redirect{FromPath: "/old/*", ToPath: "/new/*"}, "/old//x", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.rd.target(tt.path)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("target(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
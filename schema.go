@@ -0,0 +1,79 @@
+package main
+
+import "database/sql"
+
+// schema is applied in order on startup. Every statement must be safe to
+// re-run against an already migrated database.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS posts (
+		id      SERIAL PRIMARY KEY,
+		title   TEXT NOT NULL,
+		content TEXT NOT NULL
+	)`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_warning TEXT`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS source_guid TEXT`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS posts_source_guid_key ON posts (source_guid)`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS custom_head TEXT`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS custom_foot TEXT`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS excerpt TEXT`,
+	`CREATE TABLE IF NOT EXISTS not_found_log (
+		path          TEXT PRIMARY KEY,
+		last_referrer TEXT NOT NULL DEFAULT '',
+		hits          INT NOT NULL DEFAULT 1,
+		first_seen    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		last_seen     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS not_found_log_last_seen_idx ON not_found_log (last_seen)`,
+	`CREATE TABLE IF NOT EXISTS redirects (
+		from_path  TEXT PRIMARY KEY,
+		to_path    TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`ALTER TABLE redirects ALTER COLUMN to_path DROP NOT NULL`,
+	`ALTER TABLE redirects ADD COLUMN IF NOT EXISTS to_post_id INT REFERENCES posts (id) ON DELETE CASCADE`,
+	`ALTER TABLE redirects ADD COLUMN IF NOT EXISTS status INT NOT NULL DEFAULT 301`,
+	`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'redirects_target_check') THEN
+			ALTER TABLE redirects ADD CONSTRAINT redirects_target_check
+				CHECK ((to_path IS NULL) <> (to_post_id IS NULL) AND status IN (301, 302));
+		END IF;
+	END $$`,
+	`DO $$ BEGIN
+		CREATE TYPE post_level AS ENUM ('beginner', 'intermediate', 'advanced');
+	EXCEPTION WHEN duplicate_object THEN NULL;
+	END $$`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS level post_level`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS source_url TEXT`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS posts_source_url_key ON posts (source_url)`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS draft BOOLEAN NOT NULL DEFAULT FALSE`,
+	`CREATE TABLE IF NOT EXISTS series (
+		id    SERIAL PRIMARY KEY,
+		slug  TEXT NOT NULL UNIQUE,
+		title TEXT NOT NULL
+	)`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS series_id INT REFERENCES series (id) ON DELETE SET NULL`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS series_order INT CHECK (series_order > 0)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS posts_series_order_key ON posts (series_id, series_order)`,
+	`CREATE TABLE IF NOT EXISTS announcements (
+		id        SERIAL PRIMARY KEY,
+		message   TEXT NOT NULL,
+		kind      TEXT NOT NULL DEFAULT 'info' CHECK (kind IN ('info', 'warning', 'error')),
+		starts_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		ends_at   TIMESTAMPTZ NOT NULL,
+		active    BOOL NOT NULL DEFAULT TRUE
+	)`,
+	`ALTER TABLE posts ADD COLUMN IF NOT EXISTS seed_key TEXT`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS posts_seed_key_key ON posts (seed_key)`,
+	// Seeded posts used to be keyed in source_guid, the feed import key.
+	`UPDATE posts SET seed_key = source_guid, source_guid = NULL
+		WHERE seed_key IS NULL AND source_guid LIKE 'seed:%'`,
+}
+
+func migrate(db *sql.DB) error {
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
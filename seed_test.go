@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestFakerIsDeterministic(t *testing.T) {
+	a, b := NewFaker(1), NewFaker(1)
+	for i := 0; i < 20; i++ {
+		if ta, tb := a.RandomTitle(), b.RandomTitle(); ta != tb {
+			t.Fatalf("title %d: %q != %q", i, ta, tb)
+		}
+		if pa, pb := a.RandomParagraphs(1+i%4), b.RandomParagraphs(1+i%4); pa != pb {
+			t.Fatalf("paragraphs %d differ:\n%s\n---\n%s", i, pa, pb)
+		}
+	}
+}
+
+func TestFakerSeedsDiffer(t *testing.T) {
+	a, b := NewFaker(1), NewFaker(2)
+	if a.RandomParagraphs(3) == b.RandomParagraphs(3) {
+		t.Error("seeds 1 and 2 produced the same paragraphs")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+var (
+	errSeriesOrderTaken = errors.New("that part number is already used in this series")
+	errSeriesTitle      = errors.New("series title must contain letters or digits")
+)
+
+// Series groups the parts of a multi-part post, ordered by series_order.
+type Series struct {
+	ID    int          `json:"id"`
+	Slug  string       `json:"slug"`
+	Title string       `json:"title"`
+	Parts []SeriesPart `json:"parts"`
+}
+
+type SeriesPart struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Order int    `json:"series_order"`
+}
+
+// PartNumber returns the 1-based position of postID in the series, or 0 if
+// it is not a part.
+func (s *Series) PartNumber(postID int) int {
+	for i, p := range s.Parts {
+		if p.ID == postID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// assignSeries finds or creates the series named title and picks the new
+// post's place in it. An order of 0 appends the post after the last part;
+// an explicit order must not already be taken.
+func assignSeries(ctx context.Context, tx *sql.Tx, title string, order int) (seriesID, seriesOrder int, err error) {
+	slug := slugify(title)
+	if slug == "" {
+		return 0, 0, errSeriesTitle
+	}
+
+	// The no-op update locks the series row for the rest of the
+	// transaction, so concurrent posts cannot pick the same order.
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO series (slug, title) VALUES ($1, $2)
+		ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+		RETURNING id`, slug, title).Scan(&seriesID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if order == 0 {
+		err = tx.QueryRowContext(ctx,
+			"SELECT COALESCE(MAX(series_order), 0) + 1 FROM posts WHERE series_id = $1", seriesID).Scan(&order)
+		return seriesID, order, err
+	}
+
+	var taken bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM posts WHERE series_id = $1 AND series_order = $2)", seriesID, order).Scan(&taken)
+	if err != nil {
+		return 0, 0, err
+	}
+	if taken {
+		return 0, 0, errSeriesOrderTaken
+	}
+	return seriesID, order, nil
+}
+
+// loadSeries returns the series matching the given column and value, with
+// its parts in order.
+func loadSeries(ctx context.Context, column string, value any) (*Series, error) {
+	var s Series
+	err := dbFrom(ctx).QueryRowContext(ctx, "SELECT id, slug, title FROM series WHERE "+column+" = $1", value).
+		Scan(&s.ID, &s.Slug, &s.Title)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := dbFrom(ctx).QueryContext(ctx,
+		"SELECT id, title, series_order FROM posts WHERE series_id = $1 AND NOT draft ORDER BY series_order", s.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s.Parts = []SeriesPart{}
+	for rows.Next() {
+		var p SeriesPart
+		if err := rows.Scan(&p.ID, &p.Title, &p.Order); err != nil {
+			return nil, err
+		}
+		s.Parts = append(s.Parts, p)
+	}
+	return &s, rows.Err()
+}
+
+func seriesHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := loadSeries(r.Context(), "slug", r.PathValue("slug"))
+	if errors.Is(err, sql.ErrNoRows) {
+		notFound(w, r, "Series not found")
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch series", http.StatusInternalServerError)
+		return
+	}
+
+	templatesFrom(r.Context()).ExecuteTemplate(w, "series.html", series)
+}
+
+func apiSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	series, err := loadSeries(r.Context(), "slug", r.PathValue("slug"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Series not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch series", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
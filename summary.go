@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	summaryPrompt = "Summarize the following blog post in 2-3 sentences:"
+
+	defaultSummaryTokens = 150
+	maxSummaryTokens     = 300
+)
+
+// llmAPIURL, from LLM_API_URL, is an OpenAI-compatible chat completions URL.
+// Summary generation is disabled when it is empty.
+var (
+	llmAPIURL string
+	llmAPIKey string
+	llmModel  string
+)
+
+func generateSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if llmAPIURL == "" {
+		http.Error(w, "Summary generation is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	maxTokens := defaultSummaryTokens
+	if v := r.URL.Query().Get("max_tokens"); v != "" {
+		if maxTokens, err = strconv.Atoi(v); err != nil || maxTokens < 1 {
+			http.Error(w, "max_tokens must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		maxTokens = min(maxTokens, maxSummaryTokens)
+	}
+
+	var content string
+	err = dbFrom(r.Context()).QueryRowContext(r.Context(), "SELECT content FROM posts WHERE id = $1", id).Scan(&content)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load post", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	excerpt, err := summarize(ctx, content, maxTokens)
+	if err != nil {
+		http.Error(w, "Failed to generate summary", http.StatusBadGateway)
+		return
+	}
+
+	if _, err := dbFrom(r.Context()).ExecContext(r.Context(), "UPDATE posts SET excerpt = $2 WHERE id = $1", id, excerpt); err != nil {
+		http.Error(w, "Failed to save summary", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Excerpt   string `json:"excerpt"`
+		Generated bool   `json:"generated"`
+	}{excerpt, true})
+}
+
+// summarize asks the LLM for a summary of content and collects the streamed
+// completion.
+func summarize(ctx context.Context, content string, maxTokens int) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	body, err := json.Marshal(struct {
+		Model     string    `json:"model,omitempty"`
+		Messages  []message `json:"messages"`
+		MaxTokens int       `json:"max_tokens"`
+		Stream    bool      `json:"stream"`
+	}{
+		Model: llmModel,
+		Messages: []message{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: content},
+		},
+		MaxTokens: maxTokens,
+		Stream:    true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, llmAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if llmAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+llmAPIKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("LLM API returned status %d", resp.StatusCode)
+	}
+
+	// The stream is a sequence of "data: {...}" server-sent events ending
+	// with "data: [DONE]".
+	var sb strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", err
+		}
+		for _, c := range chunk.Choices {
+			sb.WriteString(c.Delta.Content)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	summary := strings.TrimSpace(sb.String())
+	if summary == "" {
+		return "", errors.New("LLM API returned an empty summary")
+	}
+	return summary, nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// defaultSiteTitle is shown when no tenant, or a tenant without a title,
+// serves the request.
+const defaultSiteTitle = "Blog"
+
+var errUnknownTenant = errors.New("unknown tenant")
+
+// Tenant is one blog hosted by this server, selected by the request's host.
+type Tenant struct {
+	Domain    string `json:"domain"`
+	DBUrl     string `json:"db_url"`
+	SiteTitle string `json:"site_title"`
+}
+
+// TenantResolver finds the tenant serving a host. It returns
+// errUnknownTenant when no tenant does.
+type TenantResolver interface {
+	Resolve(host string) (*Tenant, error)
+}
+
+// EnvTenantResolver resolves tenants from the JSON array in TENANTS, e.g.
+// [{"domain":"a.example","db_url":"postgres://...","site_title":"A"}].
+type EnvTenantResolver struct {
+	tenants map[string]*Tenant
+}
+
+func NewEnvTenantResolver(data string) (*EnvTenantResolver, error) {
+	var list []*Tenant
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		return nil, fmt.Errorf("TENANTS must be a JSON array: %w", err)
+	}
+
+	res := &EnvTenantResolver{tenants: make(map[string]*Tenant, len(list))}
+	for _, t := range list {
+		t.Domain = strings.ToLower(strings.TrimSpace(t.Domain))
+		if t.Domain == "" || t.DBUrl == "" {
+			return nil, errors.New("every tenant needs a domain and db_url")
+		}
+		if _, dup := res.tenants[t.Domain]; dup {
+			return nil, fmt.Errorf("tenant %s is listed twice", t.Domain)
+		}
+		res.tenants[t.Domain] = t
+	}
+	return res, nil
+}
+
+// Resolve matches host with its port first, so tenants can be told apart by
+// port, then without it.
+func (res *EnvTenantResolver) Resolve(host string) (*Tenant, error) {
+	host = strings.ToLower(host)
+	if t, ok := res.tenants[host]; ok {
+		return t, nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		if t, ok := res.tenants[h]; ok {
+			return t, nil
+		}
+	}
+	return nil, errUnknownTenant
+}
+
+// Tenants lists every configured tenant.
+func (res *EnvTenantResolver) Tenants() []*Tenant {
+	list := make([]*Tenant, 0, len(res.tenants))
+	for _, t := range res.tenants {
+		list = append(list, t)
+	}
+	return list
+}
+
+// site is what a request runs against: the tenant's database and its own
+// parsed templates.
+type site struct {
+	tenant *Tenant
+	db     *sql.DB
+	tmpl   *template.Template
+}
+
+// tenantPool keeps one site per tenant and one *sql.DB per database URL,
+// created on first use.
+type tenantPool struct {
+	// prepare runs once on each newly opened database, before it is used.
+	prepare func(*sql.DB) error
+
+	mu    sync.Mutex
+	dbs   map[string]*sql.DB
+	sites map[string]*site
+}
+
+func newTenantPool(prepare func(*sql.DB) error) *tenantPool {
+	return &tenantPool{prepare: prepare, dbs: map[string]*sql.DB{}, sites: map[string]*site{}}
+}
+
+func (p *tenantPool) site(t *Tenant) (*site, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if s, ok := p.sites[t.Domain]; ok {
+		return s, nil
+	}
+
+	conn, ok := p.dbs[t.DBUrl]
+	if !ok {
+		var err error
+		if conn, err = sql.Open("postgres", t.DBUrl); err != nil {
+			return nil, err
+		}
+		if err := p.prepare(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		p.dbs[t.DBUrl] = conn
+	}
+
+	tm, err := parseTemplates(t.SiteTitle)
+	if err != nil {
+		return nil, err
+	}
+	s := &site{tenant: t, db: conn, tmpl: tm}
+	p.sites[t.Domain] = s
+	return s, nil
+}
+
+// all returns every database opened so far.
+func (p *tenantPool) all() []*sql.DB {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dbs := make([]*sql.DB, 0, len(p.dbs))
+	for _, conn := range p.dbs {
+		dbs = append(dbs, conn)
+	}
+	return dbs
+}
+
+// tenants is set when TENANTS is configured; otherwise every request uses
+// the global db and tmpl.
+var tenants *tenantPool
+
+// siteDBs lists the databases background jobs and health checks cover.
+func siteDBs() []*sql.DB {
+	if tenants == nil {
+		return []*sql.DB{db}
+	}
+	return tenants.all()
+}
+
+// parseTemplates parses the page templates with siteTitle bound for them.
+func parseTemplates(siteTitle string) (*template.Template, error) {
+	if siteTitle == "" {
+		siteTitle = defaultSiteTitle
+	}
+	return template.New("").Funcs(template.FuncMap{
+		"siteTitle": func() string { return siteTitle },
+	}).ParseGlob("templates/*.html")
+}
+
+type siteKey struct{}
+
+func siteFrom(ctx context.Context) *site {
+	s, _ := ctx.Value(siteKey{}).(*site)
+	return s
+}
+
+// tenantFrom returns the tenant serving the request, or nil outside
+// multi-tenant mode.
+func tenantFrom(ctx context.Context) *Tenant {
+	if s := siteFrom(ctx); s != nil {
+		return s.tenant
+	}
+	return nil
+}
+
+// dbFrom returns the database for the request's tenant.
+func dbFrom(ctx context.Context) *sql.DB {
+	if s := siteFrom(ctx); s != nil {
+		return s.db
+	}
+	return db
+}
+
+// templatesFrom returns the templates for the request's tenant.
+func templatesFrom(ctx context.Context) *template.Template {
+	if s := siteFrom(ctx); s != nil {
+		return s.tmpl
+	}
+	return tmpl
+}
+
+// tenantMiddleware resolves the tenant for each request's host and stores
+// its site in the request context. Health checks cover the whole process
+// and are served without a tenant.
+func tenantMiddleware(resolver TenantResolver, pool *tenantPool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			t, err := resolver.Resolve(r.Host)
+			if errors.Is(err, errUnknownTenant) {
+				http.Error(w, "Site not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to resolve site", http.StatusInternalServerError)
+				return
+			}
+
+			s, err := pool.site(t)
+			if err != nil {
+				log.Printf("Failed to open site %s: %v", t.Domain, err)
+				http.Error(w, "Site unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), siteKey{}, s)))
+		})
+	}
+}
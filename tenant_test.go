@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewEnvTenantResolver(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"valid", `[{"domain":"A.example","db_url":"postgres://a"},{"domain":"b.example","db_url":"postgres://b"}]`, ""},
+		{"not an array", `{"domain":"a.example"}`, "JSON array"},
+		{"missing domain", `[{"db_url":"postgres://a"}]`, "needs a domain"},
+		{"missing db url", `[{"domain":"a.example"}]`, "needs a domain and db_url"},
+		{"duplicate", `[{"domain":"a.example","db_url":"x"},{"domain":"A.EXAMPLE","db_url":"y"}]`, "listed twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEnvTenantResolver(tt.data)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewEnvTenantResolver: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvTenantResolverResolve(t *testing.T) {
+	res, err := NewEnvTenantResolver(`[
+		{"domain":"a.example","db_url":"postgres://a"},
+		{"domain":"a.example:8081","db_url":"postgres://a2"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host   string
+		wantDB string
+	}{
+		{"a.example", "postgres://a"},
+		{"A.Example", "postgres://a"},
+		{"a.example:8080", "postgres://a"},
+		{"a.example:8081", "postgres://a2"},
+		{"b.example", ""},
+	}
+	for _, tt := range tests {
+		got, err := res.Resolve(tt.host)
+		if tt.wantDB == "" {
+			if err != errUnknownTenant {
+				t.Errorf("Resolve(%q) error = %v, want errUnknownTenant", tt.host, err)
+			}
+			continue
+		}
+		if err != nil || got.DBUrl != tt.wantDB {
+			t.Errorf("Resolve(%q) = %+v, %v, want db %s", tt.host, got, err, tt.wantDB)
+		}
+	}
+}
+
+// TestTenantMiddlewareServesTenantsByPort runs two servers on different
+// ports, each configured as its own tenant, and checks that a request to
+// either one sees that tenant's site.
+func TestTenantMiddlewareServesTenantsByPort(t *testing.T) {
+	srvA := httptest.NewUnstartedServer(nil)
+	srvB := httptest.NewUnstartedServer(nil)
+	defer srvA.Close()
+	defer srvB.Close()
+
+	list, _ := json.Marshal([]Tenant{
+		{Domain: srvA.Listener.Addr().String(), DBUrl: "postgres://localhost/blog_a", SiteTitle: "Blog A"},
+		{Domain: srvB.Listener.Addr().String(), DBUrl: "postgres://localhost/blog_b", SiteTitle: "Blog B"},
+	})
+	resolver, err := NewEnvTenantResolver(string(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prepared []*sql.DB
+	pool := newTenantPool(func(conn *sql.DB) error {
+		prepared = append(prepared, conn)
+		return nil
+	})
+	dbs := map[string]*sql.DB{}
+	handler := tenantMiddleware(resolver, pool)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title := tenantFrom(r.Context()).SiteTitle
+		dbs[title] = dbFrom(r.Context())
+		fmt.Fprint(w, title)
+	}))
+	for _, srv := range []*httptest.Server{srvA, srvB} {
+		srv.Config.Handler = handler
+		srv.Start()
+	}
+
+	get := func(url string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	for _, tt := range []struct{ url, want string }{
+		{srvA.URL + "/", "Blog A"},
+		{srvB.URL + "/", "Blog B"},
+		{srvA.URL + "/post/view?id=1", "Blog A"},
+	} {
+		if status, body := get(tt.url); status != http.StatusOK || body != tt.want {
+			t.Errorf("GET %s = %d %q, want 200 %q", tt.url, status, body, tt.want)
+		}
+	}
+
+	if dbs["Blog A"] == nil || dbs["Blog A"] == dbs["Blog B"] || dbs["Blog A"] == db {
+		t.Errorf("tenants share a database: %v", dbs)
+	}
+	if len(prepared) != 2 {
+		t.Errorf("prepared %d databases, want one per tenant", len(prepared))
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srvA.URL+"/", nil)
+	req.Host = "unknown.example"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown host status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestParseTemplatesBindsSiteTitle(t *testing.T) {
+	for _, tt := range []struct{ title, want string }{{"", defaultSiteTitle}, {"Blog A", "Blog A"}} {
+		tm, err := parseTemplates(tt.title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		probe, err := tm.New("probe").Parse("{{siteTitle}}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		if err := probe.Execute(&b, nil); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("siteTitle = %q, want %q", b.String(), tt.want)
+		}
+	}
+}
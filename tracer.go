@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxProfilesPerMinute bounds how many CPU profiles are written during a
+	// sustained slowdown.
+	maxProfilesPerMinute = 5
+
+	// profileDuration is how long each CPU profile samples for.
+	profileDuration = time.Second
+)
+
+// profileDir is where slow-request profiles are written. Tracing is
+// disabled when it is empty.
+var profileDir string
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// SlowRequestTracer captures a CPU profile after any request that takes
+// longer than threshold.
+type SlowRequestTracer struct {
+	dir       string
+	threshold time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func slowRequestTracer(threshold time.Duration) func(http.Handler) http.Handler {
+	t := &SlowRequestTracer{
+		dir:       profileDir,
+		threshold: threshold,
+		tokens:    maxProfilesPerMinute,
+		last:      time.Now(),
+	}
+	return t.Middleware
+}
+
+func (t *SlowRequestTracer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+
+		elapsed := time.Since(start)
+		if elapsed < t.threshold || !t.allow() {
+			return
+		}
+
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		log.Printf("Slow request %s %s took %v, capturing CPU profile", r.Method, r.URL.Path, elapsed)
+		go t.capture(id)
+	})
+}
+
+// allow takes a token from a bucket refilled at maxProfilesPerMinute.
+func (t *SlowRequestTracer) allow() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Minutes() * maxProfilesPerMinute
+	if t.tokens > maxProfilesPerMinute {
+		t.tokens = maxProfilesPerMinute
+	}
+	t.last = now
+
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
+
+func (t *SlowRequestTracer) capture(requestID string) {
+	path := filepath.Join(t.dir, fmt.Sprintf("%s-%d.prof", requestID, time.Now().UnixNano()))
+
+	f, err := os.Create(path)
+	if err != nil {
+		log.Printf("Failed to create profile file: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if err := pprof.StartCPUProfile(f); err != nil {
+		// Another profile is already running.
+		log.Printf("Skipping CPU profile: %v", err)
+		os.Remove(path)
+		return
+	}
+	time.Sleep(profileDuration)
+	pprof.StopCPUProfile()
+
+	log.Printf("Wrote CPU profile to %s", path)
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type profileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+func listProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entries, err := os.ReadDir(profileDir)
+	if err != nil {
+		http.Error(w, "Failed to list profiles", http.StatusInternalServerError)
+		return
+	}
+
+	profiles := []profileInfo{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".prof") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		profiles = append(profiles, profileInfo{Name: e.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].ModTime.After(profiles[j].ModTime) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+func downloadProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("file")
+	if name != filepath.Base(name) || !strings.HasSuffix(name, ".prof") {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	path := filepath.Join(profileDir, name)
+	if _, err := os.Stat(path); err != nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeFile(w, r, path)
+}